@@ -0,0 +1,263 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// UpdateStrategy identifies the mechanism used by UpdateControlPlane to roll
+// a new KubeadmControlPlane spec (and, where relevant, MachineTemplate) onto
+// an already-provisioned control plane.
+type UpdateStrategy string
+
+const (
+	// InPlaceUpdateStrategy patches the spec of the existing MachineTemplate
+	// (the one the KubeadmControlPlane already references) in place and waits
+	// for the KubeadmControlPlane to report the update without replacing any
+	// machines. The KubeadmControlPlane object itself is not modified.
+	InPlaceUpdateStrategy UpdateStrategy = "InPlace"
+
+	// RecreateUpdateStrategy scales the control plane down to zero replicas,
+	// waits for the existing machines to be deleted, and then scales the
+	// updated control plane back up.
+	RecreateUpdateStrategy UpdateStrategy = "Recreate"
+
+	// RollingUpdateUpdateStrategy creates a new MachineTemplate for the
+	// updated spec and rolls machines one generation at a time, never
+	// dropping below Replicas-MaxUnavailable.
+	RollingUpdateUpdateStrategy UpdateStrategy = "RollingUpdate"
+)
+
+// UpdateControlPlane rolls input.NewMachineTemplate onto input.ControlPlane
+// using input.UpdateStrategy.
+// Assertions:
+//  * InPlace: Status.UpdatedReplicas reaches Status.Replicas without any
+//    Machine being deleted.
+//  * Recreate: the workload cluster is observed with zero Nodes before the
+//    updated control plane is scaled back up.
+//  * RollingUpdate: the number of Running machines never drops below
+//    Replicas-MaxUnavailable, and old-hash machines are fully replaced by
+//    new-hash ones.
+func (input *ControlplaneClusterInput) UpdateControlPlane() {
+	input.SetDefaults()
+	ctx := context.Background()
+	Expect(input.Management).ToNot(BeNil())
+
+	mgmtClient, err := input.Management.GetClient()
+	Expect(err).NotTo(HaveOccurred(), "stack: %+v", err)
+
+	key := client.ObjectKey{Namespace: input.ControlPlane.GetNamespace(), Name: input.ControlPlane.GetName()}
+
+	switch input.UpdateStrategy {
+	case InPlaceUpdateStrategy:
+		input.updateInPlace(ctx, mgmtClient, key)
+	case RecreateUpdateStrategy:
+		input.updateRecreate(ctx, mgmtClient, key)
+	case RollingUpdateUpdateStrategy:
+		input.updateRollingUpdate(ctx, mgmtClient, key)
+	default:
+		Fail(fmt.Sprintf("unknown UpdateStrategy %q", input.UpdateStrategy))
+	}
+}
+
+func (input *ControlplaneClusterInput) updateInPlace(ctx context.Context, mgmtClient client.Client, key client.ObjectKey) {
+	kcp := &controlplanev1.KubeadmControlPlane{}
+	Expect(mgmtClient.Get(ctx, key, kcp)).To(Succeed())
+
+	By("patching the existing MachineTemplate's spec in place")
+	Expect(copySpecInPlace(input.MachineTemplate, input.NewMachineTemplate)).To(Succeed())
+	Expect(mgmtClient.Update(ctx, input.MachineTemplate)).To(Succeed())
+
+	By("waiting for the control plane to report the update without machine churn")
+	replicas := kcp.Status.Replicas
+	Eventually(func() (int32, error) {
+		machineList := &clusterv1.MachineList{}
+		if err := mgmtClient.List(ctx, machineList, client.InNamespace(input.Cluster.Namespace), client.MatchingLabels{clusterv1.ClusterLabelName: input.Cluster.Name, clusterv1.MachineControlPlaneLabelName: ""}); err != nil {
+			return 0, err
+		}
+		if len(machineList.Items) != int(replicas) {
+			return 0, errors.Errorf("InPlace update must not churn machines: found %d machines, want %d", len(machineList.Items), replicas)
+		}
+
+		updated := &controlplanev1.KubeadmControlPlane{}
+		if err := mgmtClient.Get(ctx, key, updated); err != nil {
+			return 0, err
+		}
+		return updated.Status.UpdatedReplicas, nil
+	}, input.CreateTimeout, eventuallyInterval).Should(Equal(replicas))
+}
+
+func (input *ControlplaneClusterInput) updateRecreate(ctx context.Context, mgmtClient client.Client, key client.ObjectKey) {
+	kcp := &controlplanev1.KubeadmControlPlane{}
+	Expect(mgmtClient.Get(ctx, key, kcp)).To(Succeed())
+	replicas := kcp.Spec.Replicas
+
+	By("scaling the KubeadmControlPlane down to 0 replicas")
+	zero := int32(0)
+	kcp.Spec.Replicas = &zero
+	Expect(mgmtClient.Update(ctx, kcp)).To(Succeed())
+
+	lbl, err := labels.ValidatedSelectorFromSet(labels.Set{clusterv1.ClusterLabelName: input.Cluster.Name, clusterv1.MachineControlPlaneLabelName: ""})
+	Expect(err).ToNot(HaveOccurred())
+	listOpts := &client.ListOptions{LabelSelector: lbl}
+
+	By("waiting for the existing control plane machines to be deleted")
+	Eventually(func() error {
+		return checkArtifactsDeleted(ctx, mgmtClient, listOpts)
+	}, input.CreateTimeout, eventuallyInterval).Should(Succeed())
+
+	By("observing zero nodes in the workload cluster before scaling back up")
+	Eventually(func() (int, error) {
+		workloadClient, err := input.Management.GetWorkloadClient(ctx, input.Cluster.Namespace, input.Cluster.Name)
+		if err != nil {
+			return -1, errors.Wrap(err, "failed to get workload client")
+		}
+		nodeList := v1.NodeList{}
+		if err := workloadClient.List(ctx, &nodeList); err != nil {
+			return -1, err
+		}
+		return len(nodeList.Items), nil
+	}, input.CreateTimeout, eventuallyInterval).Should(Equal(0))
+
+	By("applying the new control plane template and scaling back up")
+	Expect(mgmtClient.Create(ctx, input.NewMachineTemplate)).To(Succeed())
+	kcp.Spec.InfrastructureTemplate = *infraTemplateRef(input.NewMachineTemplate)
+	kcp.Spec.Replicas = replicas
+	Expect(mgmtClient.Update(ctx, kcp)).To(Succeed())
+
+	Eventually(func() (int32, error) {
+		updated := &controlplanev1.KubeadmControlPlane{}
+		if err := mgmtClient.Get(ctx, key, updated); err != nil {
+			return 0, err
+		}
+		return updated.Status.Replicas, nil
+	}, input.CreateTimeout, eventuallyInterval).Should(Equal(*replicas))
+}
+
+func (input *ControlplaneClusterInput) updateRollingUpdate(ctx context.Context, mgmtClient client.Client, key client.ObjectKey) {
+	kcp := &controlplanev1.KubeadmControlPlane{}
+	Expect(mgmtClient.Get(ctx, key, kcp)).To(Succeed())
+	replicas := *kcp.Spec.Replicas
+
+	oldHash := specHash(kcp.Spec.InfrastructureTemplate)
+	newHash := specHash(input.NewMachineTemplate)
+
+	newTemplate := input.NewMachineTemplate.DeepCopyObject()
+	accessor, err := meta.Accessor(newTemplate)
+	Expect(err).NotTo(HaveOccurred())
+	accessor.SetName(fmt.Sprintf("%s-%s", accessor.GetName(), newHash))
+
+	By(fmt.Sprintf("creating the new MachineTemplate %s", accessor.GetName()))
+	Expect(mgmtClient.Create(ctx, newTemplate)).To(Succeed())
+
+	By("patching KubeadmControlPlane.Spec.InfrastructureTemplate to point at the new template")
+	kcp.Spec.InfrastructureTemplate = *infraTemplateRef(newTemplate)
+	Expect(mgmtClient.Update(ctx, kcp)).To(Succeed())
+
+	minAvailable := replicas - input.MaxUnavailable
+
+	By("waiting for old-hash machines to be replaced by new-hash machines")
+	Eventually(func() (bool, error) {
+		machineList := &clusterv1.MachineList{}
+		if err := mgmtClient.List(ctx, machineList, client.InNamespace(input.Cluster.Namespace), client.MatchingLabels{clusterv1.ClusterLabelName: input.Cluster.Name, clusterv1.MachineControlPlaneLabelName: ""}); err != nil {
+			return false, err
+		}
+
+		var running, old int32
+		for i := range machineList.Items {
+			m := &machineList.Items[i]
+			if machineHash(m) == oldHash {
+				old++
+			}
+			if m.Status.Phase == string(clusterv1.MachinePhaseRunning) {
+				running++
+			}
+		}
+		if running < minAvailable {
+			return false, errors.Errorf("only %d of %d machines running, below Replicas-MaxUnavailable=%d", running, replicas, minAvailable)
+		}
+		return old == 0, nil
+	}, input.CreateTimeout, eventuallyInterval).Should(BeTrue())
+}
+
+// infraTemplateRef builds the ObjectReference cluster-api stores on
+// KubeadmControlPlane.Spec.InfrastructureTemplate for the given template.
+func infraTemplateRef(template runtime.Object) *v1.ObjectReference {
+	accessor, err := meta.Accessor(template)
+	Expect(err).NotTo(HaveOccurred())
+	return &v1.ObjectReference{
+		Kind:       template.GetObjectKind().GroupVersionKind().Kind,
+		Namespace:  accessor.GetNamespace(),
+		Name:       accessor.GetName(),
+		APIVersion: template.GetObjectKind().GroupVersionKind().GroupVersion().String(),
+	}
+}
+
+// copySpecInPlace overwrites dst's spec field with src's, leaving dst's
+// identity (namespace, name, kind) untouched, so callers that already hold
+// a reference to dst (e.g. an InfrastructureTemplate a KubeadmControlPlane
+// points at) can mutate it without repointing that reference. dst and src
+// need not share a concrete Go type, only a "spec" field, which is why this
+// goes through the unstructured converter rather than a type assertion.
+func copySpecInPlace(dst, src runtime.Object) error {
+	srcMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(src)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert source template to unstructured")
+	}
+	spec, ok := srcMap["spec"]
+	if !ok {
+		return errors.Errorf("%T has no spec field", src)
+	}
+
+	dstMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(dst)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert destination template to unstructured")
+	}
+	dstMap["spec"] = spec
+
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(dstMap, dst)
+}
+
+// specHash returns a short, stable hash of a template or object reference,
+// used to suffix generated MachineTemplate names and to identify which
+// generation a Machine was created from.
+func specHash(obj interface{}) string {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%+v", obj)
+	return fmt.Sprintf("%x", h.Sum32())
+}
+
+// machineHash extracts the generation hash a Machine's infrastructure ref
+// was created from, if present.
+func machineHash(m *clusterv1.Machine) string {
+	return specHash(m.Spec.InfrastructureRef)
+}
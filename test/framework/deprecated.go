@@ -27,17 +27,20 @@ import (
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	cabpkv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
-
 	"k8s.io/apimachinery/pkg/runtime"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	cabpkv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
 	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/test/framework/waitfor"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
 	// eventuallyInterval is the polling interval used by gomega's Eventually
-	// Deprecated
+	// Deprecated: ControlPlaneCluster and CleanUpCoreArtifacts now wait on
+	// watches via the waitfor package instead of polling on this interval;
+	// it remains for the handful of create-retry loops below and for other
+	// callers of the deprecated helpers in this file.
 	eventuallyInterval = 10 * time.Second
 )
 
@@ -57,6 +60,11 @@ type Node struct {
 	Machine         *clusterv1.Machine
 	InfraMachine    runtime.Object
 	BootstrapConfig runtime.Object
+
+	// SSHProbe is used to assert reachability of the underlying host when
+	// the owning ControlplaneClusterInput.NodeProfile is BareMetalNodeProfile.
+	// It is ignored for CloudNodeProfile.
+	SSHProbe SSHProbe
 }
 
 // ControlplaneClusterInput defines the necessary dependencies to run a multi-node control plane cluster.
@@ -73,6 +81,32 @@ type ControlplaneClusterInput struct {
 
 	ControlPlane    *controlplanev1.KubeadmControlPlane
 	MachineTemplate runtime.Object
+
+	// NodeProfile determines what ControlPlaneCluster must check before it
+	// trusts Machine.Status.Phase. Defaults to CloudNodeProfile.
+	NodeProfile NodeProfile
+
+	// UpdateStrategy controls how UpdateControlPlane rolls a new
+	// ControlPlane/MachineTemplate spec onto an already-provisioned
+	// control plane. Defaults to RollingUpdateUpdateStrategy.
+	UpdateStrategy UpdateStrategy
+
+	// NewMachineTemplate is the MachineTemplate UpdateControlPlane rolls
+	// out. For InPlaceUpdateStrategy it is used to patch MachineTemplate
+	// in place; for RecreateUpdateStrategy it becomes the
+	// InfrastructureTemplate referenced by the updated KCP, and for
+	// RollingUpdateUpdateStrategy a copy of it, its name suffixed with a hash
+	// of its spec, does.
+	NewMachineTemplate runtime.Object
+
+	// MaxUnavailable bounds how many control plane replicas
+	// RollingUpdateUpdateStrategy may take out of service at once.
+	// Defaults to 1.
+	MaxUnavailable int32
+
+	// RemediationScenario configures AssertRemediation. It is only required
+	// by callers of that method.
+	RemediationScenario RemediationScenario
 }
 
 // SetDefaults defaults the struct fields if necessary.
@@ -85,6 +119,18 @@ func (input *ControlplaneClusterInput) SetDefaults() {
 	if input.DeleteTimeout == 0 {
 		input.DeleteTimeout = 5 * time.Minute
 	}
+
+	if input.NodeProfile == "" {
+		input.NodeProfile = CloudNodeProfile
+	}
+
+	if input.UpdateStrategy == "" {
+		input.UpdateStrategy = RollingUpdateUpdateStrategy
+	}
+
+	if input.MaxUnavailable == 0 {
+		input.MaxUnavailable = 1
+	}
 }
 
 // ControlPlaneCluster creates an n node control plane cluster.
@@ -137,17 +183,10 @@ func (input *ControlplaneClusterInput) ControlPlaneCluster() {
 	}, input.CreateTimeout, 10*time.Second).Should(BeNil())
 
 	By("waiting for cluster to enter the provisioned phase")
-	Eventually(func() (string, error) {
-		cluster := &clusterv1.Cluster{}
-		key := client.ObjectKey{
-			Namespace: input.Cluster.GetNamespace(),
-			Name:      input.Cluster.GetName(),
-		}
-		if err := mgmtClient.Get(ctx, key, cluster); err != nil {
-			return "", err
-		}
-		return cluster.Status.Phase, nil
-	}, input.CreateTimeout, eventuallyInterval).Should(Equal(string(clusterv1.ClusterPhaseProvisioned)))
+	clusterKey := client.ObjectKey{Namespace: input.Cluster.GetNamespace(), Name: input.Cluster.GetName()}
+	Expect(waitfor.For(ctx, input.Management, input.CreateTimeout,
+		waitfor.ClusterPhase(clusterKey, clusterv1.ClusterPhaseProvisioned),
+	)).To(Succeed())
 
 	// Create the machine deployment if the replica count >0.
 	if machineDeployment := input.MachineDeployment.MachineDeployment; machineDeployment != nil {
@@ -163,49 +202,27 @@ func (input *ControlplaneClusterInput) ControlPlaneCluster() {
 		}
 
 		By("waiting for the workload nodes to exist")
-		Eventually(func() ([]v1.Node, error) {
-			workloadClient, err := input.Management.GetWorkloadClient(ctx, input.Cluster.Namespace, input.Cluster.Name)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to get workload client")
-			}
-			nodeList := v1.NodeList{}
-			if err := workloadClient.List(ctx, &nodeList); err != nil {
-				return nil, err
-			}
-			return nodeList.Items, nil
-		}, input.CreateTimeout, 10*time.Second).Should(HaveLen(int(*machineDeployment.Spec.Replicas)))
+		Expect(waitfor.For(ctx, input.Management, input.CreateTimeout,
+			waitfor.WorkloadNodesReady(input.Cluster.Namespace, input.Cluster.Name, int(*machineDeployment.Spec.Replicas)),
+		)).To(Succeed())
 	}
 
+	input.waitForBareMetalNodesReady(ctx)
+
 	By("waiting for all machines to be running")
-	inClustersNamespaceListOption := client.InNamespace(input.Cluster.Namespace)
-	matchClusterListOption := client.MatchingLabels{clusterv1.ClusterLabelName: input.Cluster.Name}
-	Eventually(func() (bool, error) {
-		// Get a list of all the Machine resources that belong to the Cluster.
-		machineList := &clusterv1.MachineList{}
-		if err := mgmtClient.List(ctx, machineList, inClustersNamespaceListOption, matchClusterListOption); err != nil {
-			return false, err
-		}
-		for _, machine := range machineList.Items {
-			if machine.Status.Phase != string(clusterv1.MachinePhaseRunning) {
-				return false, errors.Errorf("machine %s is not running, it's %s", machine.Name, machine.Status.Phase)
-			}
-		}
-		return true, nil
-	}, input.CreateTimeout, eventuallyInterval).Should(BeTrue())
-	// wait for the control plane to be ready
+	expectedMachines := int(*input.ControlPlane.Spec.Replicas)
+	if machineDeployment := input.MachineDeployment.MachineDeployment; machineDeployment != nil && machineDeployment.Spec.Replicas != nil {
+		expectedMachines += int(*machineDeployment.Spec.Replicas)
+	}
+	matchClusterSelector, err := labels.ValidatedSelectorFromSet(labels.Set{clusterv1.ClusterLabelName: input.Cluster.Name})
+	Expect(err).NotTo(HaveOccurred())
+
+	controlPlaneKey := client.ObjectKey{Namespace: input.ControlPlane.GetNamespace(), Name: input.ControlPlane.GetName()}
 	By("waiting for the control plane to be ready")
-	Eventually(func() bool {
-		controlplane := &controlplanev1.KubeadmControlPlane{}
-		key := client.ObjectKey{
-			Namespace: input.ControlPlane.GetNamespace(),
-			Name:      input.ControlPlane.GetName(),
-		}
-		if err := mgmtClient.Get(ctx, key, controlplane); err != nil {
-			fmt.Println(err.Error())
-			return false
-		}
-		return controlplane.Status.Initialized
-	}, input.CreateTimeout, 10*time.Second).Should(BeTrue())
+	Expect(waitfor.For(ctx, input.Management, input.CreateTimeout,
+		waitfor.MachinesRunning(input.Cluster.Namespace, matchClusterSelector, expectedMachines),
+		waitfor.KCPInitialized(controlPlaneKey),
+	)).To(Succeed())
 }
 
 // CleanUpCoreArtifacts deletes the cluster and waits for everything to be gone.
@@ -225,14 +242,10 @@ func (input *ControlplaneClusterInput) CleanUpCoreArtifacts() {
 	By(fmt.Sprintf("deleting cluster %s", input.Cluster.GetName()))
 	Expect(mgmtClient.Delete(ctx, input.Cluster)).To(Succeed())
 
-	Eventually(func() bool {
-		clusters := clusterv1.ClusterList{}
-		if err := mgmtClient.List(ctx, &clusters); err != nil {
-			fmt.Println(err.Error())
-			return false
-		}
-		return len(clusters.Items) == 0
-	}, input.DeleteTimeout, eventuallyInterval).Should(BeTrue())
+	clusterKey := client.ObjectKey{Namespace: input.Cluster.GetNamespace(), Name: input.Cluster.GetName()}
+	Expect(waitfor.For(ctx, input.Management, input.DeleteTimeout,
+		waitfor.ClusterDeleted(clusterKey),
+	)).To(Succeed())
 
 	lbl, err := labels.Parse(fmt.Sprintf("%s=%s", clusterv1.ClusterLabelName, input.Cluster.GetClusterName()))
 	Expect(err).ToNot(HaveOccurred())
@@ -244,28 +257,62 @@ func (input *ControlplaneClusterInput) CleanUpCoreArtifacts() {
 
 // Deprecated
 func ensureArtifactsDeleted(ctx context.Context, mgmtClient Lister, opt client.ListOption) {
-	// assertions
+	Expect(checkArtifactsDeleted(ctx, mgmtClient, opt)).To(Succeed())
+}
+
+// checkArtifactsDeleted returns an error describing the first CAPI kind
+// found with leftover items owned by opt's selector, or nil once every kind
+// is empty. It exists (rather than folding straight into
+// ensureArtifactsDeleted) so callers without ensureArtifactsDeleted's
+// preceding "owning Cluster is already gone" invariant can retry it inside
+// an Eventually instead of asserting on a single List.
+func checkArtifactsDeleted(ctx context.Context, mgmtClient Lister, opt client.ListOption) error {
 	ml := &clusterv1.MachineList{}
-	Expect(mgmtClient.List(ctx, ml, opt)).To(Succeed())
-	Expect(ml.Items).To(HaveLen(0))
+	if err := mgmtClient.List(ctx, ml, opt); err != nil {
+		return err
+	}
+	if len(ml.Items) != 0 {
+		return errors.Errorf("%d Machines still present", len(ml.Items))
+	}
 
 	msl := &clusterv1.MachineSetList{}
-	Expect(mgmtClient.List(ctx, msl, opt)).To(Succeed())
-	Expect(msl.Items).To(HaveLen(0))
+	if err := mgmtClient.List(ctx, msl, opt); err != nil {
+		return err
+	}
+	if len(msl.Items) != 0 {
+		return errors.Errorf("%d MachineSets still present", len(msl.Items))
+	}
 
 	mdl := &clusterv1.MachineDeploymentList{}
-	Expect(mgmtClient.List(ctx, mdl, opt)).To(Succeed())
-	Expect(mdl.Items).To(HaveLen(0))
+	if err := mgmtClient.List(ctx, mdl, opt); err != nil {
+		return err
+	}
+	if len(mdl.Items) != 0 {
+		return errors.Errorf("%d MachineDeployments still present", len(mdl.Items))
+	}
 
 	kcpl := &controlplanev1.KubeadmControlPlaneList{}
-	Expect(mgmtClient.List(ctx, kcpl, opt)).To(Succeed())
-	Expect(kcpl.Items).To(HaveLen(0))
+	if err := mgmtClient.List(ctx, kcpl, opt); err != nil {
+		return err
+	}
+	if len(kcpl.Items) != 0 {
+		return errors.Errorf("%d KubeadmControlPlanes still present", len(kcpl.Items))
+	}
 
 	kcl := &cabpkv1.KubeadmConfigList{}
-	Expect(mgmtClient.List(ctx, kcl, opt)).To(Succeed())
-	Expect(kcl.Items).To(HaveLen(0))
+	if err := mgmtClient.List(ctx, kcl, opt); err != nil {
+		return err
+	}
+	if len(kcl.Items) != 0 {
+		return errors.Errorf("%d KubeadmConfigs still present", len(kcl.Items))
+	}
 
 	sl := &v1.SecretList{}
-	Expect(mgmtClient.List(ctx, sl, opt)).To(Succeed())
-	Expect(sl.Items).To(HaveLen(0))
+	if err := mgmtClient.List(ctx, sl, opt); err != nil {
+		return err
+	}
+	if len(sl.Items) != 0 {
+		return errors.Errorf("%d Secrets still present", len(sl.Items))
+	}
+	return nil
 }
@@ -0,0 +1,332 @@
+// Hand-written Go types and gRPC client/server stubs for the
+// ManagementCluster service described in management.proto. This is not
+// protoc output; the message types are plain structs carried by the JSON
+// codec in codec.go.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// Empty is returned by RPCs that have no response payload.
+type Empty struct{}
+
+// Object carries a single Kubernetes object serialized as JSON, along with
+// its GroupVersionKind so the receiving side can decode it without needing
+// the scheme registered.
+type Object struct {
+	APIVersion string
+	Kind       string
+	JSON       []byte
+}
+
+// ObjectList is the response type for List.
+type ObjectList struct {
+	Items []*Object
+}
+
+// GetRequest identifies a single object to fetch.
+type GetRequest struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+// ListRequest identifies a set of objects to list or watch.
+type ListRequest struct {
+	APIVersion    string
+	Kind          string
+	Namespace     string
+	LabelSelector map[string]string
+}
+
+// WatchEventType mirrors client-go's watch.EventType.
+type WatchEventType int32
+
+const (
+	WatchEventType_ADDED    WatchEventType = 0
+	WatchEventType_MODIFIED WatchEventType = 1
+	WatchEventType_DELETED  WatchEventType = 2
+)
+
+// WatchEvent is streamed by Watch.
+type WatchEvent struct {
+	Type   WatchEventType
+	Object *Object
+}
+
+// WorkloadClientRequest identifies the workload cluster whose kubeconfig is
+// being requested.
+type WorkloadClientRequest struct {
+	Namespace string
+	Name      string
+}
+
+// Chunk is a piece of a streamed kubeconfig.
+type Chunk struct {
+	Data []byte
+}
+
+// ManagementClusterClient is the client API for the ManagementCluster
+// gRPC service.
+type ManagementClusterClient interface {
+	Create(ctx context.Context, in *Object, opts ...grpc.CallOption) (*Empty, error)
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Object, error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ObjectList, error)
+	Delete(ctx context.Context, in *Object, opts ...grpc.CallOption) (*Empty, error)
+	Watch(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (ManagementCluster_WatchClient, error)
+	GetWorkloadClient(ctx context.Context, in *WorkloadClientRequest, opts ...grpc.CallOption) (ManagementCluster_GetWorkloadClientClient, error)
+}
+
+// ManagementCluster_WatchClient is the stream returned by Watch.
+type ManagementCluster_WatchClient interface {
+	Recv() (*WatchEvent, error)
+	grpc.ClientStream
+}
+
+// ManagementCluster_GetWorkloadClientClient is the stream returned by
+// GetWorkloadClient.
+type ManagementCluster_GetWorkloadClientClient interface {
+	Recv() (*Chunk, error)
+	grpc.ClientStream
+}
+
+type managementClusterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewManagementClusterClient returns a ManagementClusterClient backed by cc.
+func NewManagementClusterClient(cc grpc.ClientConnInterface) ManagementClusterClient {
+	return &managementClusterClient{cc}
+}
+
+func (c *managementClusterClient) Create(ctx context.Context, in *Object, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	if err := c.cc.Invoke(ctx, "/management.v1alpha1.ManagementCluster/Create", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClusterClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Object, error) {
+	out := new(Object)
+	if err := c.cc.Invoke(ctx, "/management.v1alpha1.ManagementCluster/Get", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClusterClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ObjectList, error) {
+	out := new(ObjectList)
+	if err := c.cc.Invoke(ctx, "/management.v1alpha1.ManagementCluster/List", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClusterClient) Delete(ctx context.Context, in *Object, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	if err := c.cc.Invoke(ctx, "/management.v1alpha1.ManagementCluster/Delete", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClusterClient) Watch(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (ManagementCluster_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_ManagementCluster_serviceDesc.Streams[0], "/management.v1alpha1.ManagementCluster/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &managementClusterWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type managementClusterWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *managementClusterWatchClient) Recv() (*WatchEvent, error) {
+	m := new(WatchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *managementClusterClient) GetWorkloadClient(ctx context.Context, in *WorkloadClientRequest, opts ...grpc.CallOption) (ManagementCluster_GetWorkloadClientClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_ManagementCluster_serviceDesc.Streams[1], "/management.v1alpha1.ManagementCluster/GetWorkloadClient", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &managementClusterGetWorkloadClientClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type managementClusterGetWorkloadClientClient struct {
+	grpc.ClientStream
+}
+
+func (x *managementClusterGetWorkloadClientClient) Recv() (*Chunk, error) {
+	m := new(Chunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ManagementClusterServer is the server API for the ManagementCluster gRPC
+// service.
+type ManagementClusterServer interface {
+	Create(context.Context, *Object) (*Empty, error)
+	Get(context.Context, *GetRequest) (*Object, error)
+	List(context.Context, *ListRequest) (*ObjectList, error)
+	Delete(context.Context, *Object) (*Empty, error)
+	Watch(*ListRequest, ManagementCluster_WatchServer) error
+	GetWorkloadClient(*WorkloadClientRequest, ManagementCluster_GetWorkloadClientServer) error
+}
+
+// ManagementCluster_WatchServer is the stream a Watch implementation sends
+// events on.
+type ManagementCluster_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+// ManagementCluster_GetWorkloadClientServer is the stream a
+// GetWorkloadClient implementation sends kubeconfig chunks on.
+type ManagementCluster_GetWorkloadClientServer interface {
+	Send(*Chunk) error
+	grpc.ServerStream
+}
+
+// UnimplementedManagementClusterServer can be embedded in a
+// ManagementClusterServer implementation to get forward-compatible
+// behavior: methods added to the interface in the future return
+// "not implemented" instead of failing to compile.
+type UnimplementedManagementClusterServer struct{}
+
+func (UnimplementedManagementClusterServer) Create(context.Context, *Object) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedManagementClusterServer) Get(context.Context, *GetRequest) (*Object, error) {
+	return nil, status.Error(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedManagementClusterServer) List(context.Context, *ListRequest) (*ObjectList, error) {
+	return nil, status.Error(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedManagementClusterServer) Delete(context.Context, *Object) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedManagementClusterServer) Watch(*ListRequest, ManagementCluster_WatchServer) error {
+	return status.Error(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedManagementClusterServer) GetWorkloadClient(*WorkloadClientRequest, ManagementCluster_GetWorkloadClientServer) error {
+	return status.Error(codes.Unimplemented, "method GetWorkloadClient not implemented")
+}
+
+// RegisterManagementClusterServer registers srv with s.
+func RegisterManagementClusterServer(s grpc.ServiceRegistrar, srv ManagementClusterServer) {
+	s.RegisterService(&_ManagementCluster_serviceDesc, srv)
+}
+
+type managementClusterWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *managementClusterWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+type managementClusterGetWorkloadClientServer struct {
+	grpc.ServerStream
+}
+
+func (x *managementClusterGetWorkloadClientServer) Send(m *Chunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ManagementCluster_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Object)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	return srv.(ManagementClusterServer).Create(ctx, in)
+}
+
+func _ManagementCluster_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	return srv.(ManagementClusterServer).Get(ctx, in)
+}
+
+func _ManagementCluster_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	return srv.(ManagementClusterServer).List(ctx, in)
+}
+
+func _ManagementCluster_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Object)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	return srv.(ManagementClusterServer).Delete(ctx, in)
+}
+
+var _ManagementCluster_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "management.v1alpha1.ManagementCluster",
+	HandlerType: (*ManagementClusterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Create", Handler: _ManagementCluster_Create_Handler},
+		{MethodName: "Get", Handler: _ManagementCluster_Get_Handler},
+		{MethodName: "List", Handler: _ManagementCluster_List_Handler},
+		{MethodName: "Delete", Handler: _ManagementCluster_Delete_Handler},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Watch",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				m := new(ListRequest)
+				if err := stream.RecvMsg(m); err != nil {
+					return err
+				}
+				return srv.(ManagementClusterServer).Watch(m, &managementClusterWatchServer{stream})
+			},
+			ServerStreams: true,
+		},
+		{
+			StreamName: "GetWorkloadClient",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				m := new(WorkloadClientRequest)
+				if err := stream.RecvMsg(m); err != nil {
+					return err
+				}
+				return srv.(ManagementClusterServer).GetWorkloadClient(m, &managementClusterGetWorkloadClientServer{stream})
+			},
+			ServerStreams: true,
+		},
+	},
+	Metadata: "management.proto",
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pb "sigs.k8s.io/cluster-api/test/framework/management/v1alpha1"
+)
+
+// kubeconfigChunkSize bounds how much of a workload cluster's kubeconfig is
+// sent per Chunk, so large embedded CA bundles don't blow past gRPC's
+// default per-message size limit.
+const kubeconfigChunkSize = 32 * 1024
+
+// ManagementServer implements pb.ManagementClusterServer by wrapping a real
+// controller-runtime client.Client, and a WorkloadKubeconfigGetter capable
+// of producing a workload cluster's kubeconfig bytes on demand. It is the
+// counterpart RemoteManagement dials into.
+type ManagementServer struct {
+	pb.UnimplementedManagementClusterServer
+
+	Client               client.Client
+	WorkloadKubeconfigFn func(ctx context.Context, namespace, name string) ([]byte, error)
+}
+
+func (s *ManagementServer) Create(ctx context.Context, in *pb.Object) (*pb.Empty, error) {
+	u, err := toUnstructured(in)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Client.Create(ctx, u); err != nil {
+		return nil, err
+	}
+	return &pb.Empty{}, nil
+}
+
+func (s *ManagementServer) Delete(ctx context.Context, in *pb.Object) (*pb.Empty, error) {
+	u, err := toUnstructured(in)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Client.Delete(ctx, u); err != nil {
+		return nil, err
+	}
+	return &pb.Empty{}, nil
+}
+
+func (s *ManagementServer) Get(ctx context.Context, in *pb.GetRequest) (*pb.Object, error) {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(schema.FromAPIVersionAndKind(in.APIVersion, in.Kind))
+
+	key := client.ObjectKey{Namespace: in.Namespace, Name: in.Name}
+	if err := s.Client.Get(ctx, key, u); err != nil {
+		return nil, err
+	}
+	return fromUnstructured(u)
+}
+
+func (s *ManagementServer) List(ctx context.Context, in *pb.ListRequest) (*pb.ObjectList, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.FromAPIVersionAndKind(in.APIVersion, in.Kind+"List"))
+
+	opts := []client.ListOption{client.InNamespace(in.Namespace)}
+	if len(in.LabelSelector) > 0 {
+		opts = append(opts, client.MatchingLabels(in.LabelSelector))
+	}
+
+	if err := s.Client.List(ctx, list, opts...); err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ObjectList{}
+	for i := range list.Items {
+		obj, err := fromUnstructured(&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		resp.Items = append(resp.Items, obj)
+	}
+	return resp, nil
+}
+
+func (s *ManagementServer) Watch(in *pb.ListRequest, stream pb.ManagementCluster_WatchServer) error {
+	// Watching needs a Client that implements client.WithWatch; with any
+	// other Client, Watch returns an error and streams nothing.
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.FromAPIVersionAndKind(in.APIVersion, in.Kind+"List"))
+
+	opts := []client.ListOption{client.InNamespace(in.Namespace)}
+	if len(in.LabelSelector) > 0 {
+		opts = append(opts, client.MatchingLabels(in.LabelSelector))
+	}
+
+	watcher, ok := s.Client.(client.WithWatch)
+	if !ok {
+		return errors.New("underlying client does not support Watch")
+	}
+	w, err := watcher.Watch(stream.Context(), list, opts...)
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	for event := range w.ResultChan() {
+		u, ok := event.Object.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		obj, err := fromUnstructured(u)
+		if err != nil {
+			return err
+		}
+
+		var t pb.WatchEventType
+		switch event.Type {
+		case watch.Added:
+			t = pb.WatchEventType_ADDED
+		case watch.Modified:
+			t = pb.WatchEventType_MODIFIED
+		case watch.Deleted:
+			t = pb.WatchEventType_DELETED
+		default:
+			continue
+		}
+
+		if err := stream.Send(&pb.WatchEvent{Type: t, Object: obj}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ManagementServer) GetWorkloadClient(in *pb.WorkloadClientRequest, stream pb.ManagementCluster_GetWorkloadClientServer) error {
+	if s.WorkloadKubeconfigFn == nil {
+		return errors.New("ManagementServer has no WorkloadKubeconfigFn configured")
+	}
+	kubeconfig, err := s.WorkloadKubeconfigFn(stream.Context(), in.Namespace, in.Name)
+	if err != nil {
+		return errors.Wrap(err, "failed to build workload kubeconfig")
+	}
+	// Validate before streaming so a malformed kubeconfig fails fast rather
+	// than surfacing as a confusing client-side parse error.
+	if _, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig); err != nil {
+		return errors.Wrap(err, "workload kubeconfig is invalid")
+	}
+
+	for i := 0; i < len(kubeconfig); i += kubeconfigChunkSize {
+		end := i + kubeconfigChunkSize
+		if end > len(kubeconfig) {
+			end = len(kubeconfig)
+		}
+		if err := stream.Send(&pb.Chunk{Data: kubeconfig[i:end]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toUnstructured(obj *pb.Object) (*unstructured.Unstructured, error) {
+	u := &unstructured.Unstructured{}
+	if err := json.Unmarshal(obj.JSON, &u.Object); err != nil {
+		return nil, errors.Wrap(err, "failed to decode object")
+	}
+	if u.GroupVersionKind().Empty() {
+		u.SetGroupVersionKind(schema.FromAPIVersionAndKind(obj.APIVersion, obj.Kind))
+	}
+	return u, nil
+}
+
+func fromUnstructured(u *unstructured.Unstructured) (*pb.Object, error) {
+	raw, err := json.Marshal(u.Object)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode object")
+	}
+	gvk := u.GroupVersionKind()
+	return &pb.Object{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		JSON:       raw,
+	}, nil
+}
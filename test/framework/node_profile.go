@@ -0,0 +1,164 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NodeProfile identifies the kind of infrastructure backing a Node, which
+// determines what ControlPlaneCluster must check before it is willing to
+// assert on Machine.Status.Phase.
+type NodeProfile string
+
+const (
+	// CloudNodeProfile is the default: InfraMachines are expected to
+	// transition to Running entirely on their own.
+	CloudNodeProfile NodeProfile = "Cloud"
+
+	// BareMetalNodeProfile marks a Node as pre-provisioned/physical. The
+	// framework dials its SSHProbe and waits for the bootstrap sentinel
+	// file before trusting Machine.Status.Phase.
+	BareMetalNodeProfile NodeProfile = "BareMetal"
+)
+
+// bootstrapSentinelFile is the marker file a kubeadm/k0s-style bootstrap
+// script is expected to create once cloud-init/userdata has finished
+// running on a bare-metal host.
+const bootstrapSentinelFile = "/run/cluster-api/bootstrap-success"
+
+// SSHProbe describes how to reach the host backing a bare-metal Node so the
+// framework can confirm it is up and bootstrapped before waiting on the
+// Machine object.
+type SSHProbe struct {
+	// Address is the host or IP of the remote machine.
+	Address string
+
+	// Port defaults to 22.
+	Port int32
+
+	// User is the SSH user to authenticate as.
+	User string
+
+	// PrivateKeySecretRef references a Secret in the management cluster
+	// containing an "ssh-privatekey" data key.
+	PrivateKeySecretRef v1.SecretReference
+}
+
+// waitForBareMetalNodesReady dials the SSHProbe of every BareMetal Node in
+// input.Nodes and waits for the bootstrap sentinel file to appear, bounded
+// by input.CreateTimeout. It is a no-op for CloudNodeProfile.
+func (input *ControlplaneClusterInput) waitForBareMetalNodesReady(ctx context.Context) {
+	if input.NodeProfile != BareMetalNodeProfile {
+		return
+	}
+
+	mgmtClient, err := input.Management.GetClient()
+	Expect(err).NotTo(HaveOccurred(), "stack: %+v", err)
+
+	for i := range input.Nodes {
+		node := input.Nodes[i]
+		By(fmt.Sprintf("waiting for bare-metal host %s to finish bootstrapping", node.SSHProbe.Address))
+		signer, err := sshSignerForSecret(ctx, mgmtClient, node.SSHProbe.PrivateKeySecretRef)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() error {
+			return probeBootstrapSentinel(node.SSHProbe, signer)
+		}, input.CreateTimeout, eventuallyInterval).Should(Succeed())
+	}
+}
+
+// dialSSH connects to probe's host using signer for authentication.
+func dialSSH(probe SSHProbe, signer ssh.Signer) (*ssh.Client, error) {
+	port := probe.Port
+	if port == 0 {
+		port = 22
+	}
+
+	config := &ssh.ClientConfig{
+		User:            probe.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(), //nolint:gosec // e2e test hosts are ephemeral and not pinned in advance.
+		Timeout:         10 * time.Second,
+	}
+
+	addr := net.JoinHostPort(probe.Address, fmt.Sprintf("%d", port))
+	conn, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dial %s", addr)
+	}
+	return conn, nil
+}
+
+// runSSHCommand dials probe over SSH and runs cmd, returning any error the
+// remote command exits with.
+func runSSHCommand(probe SSHProbe, signer ssh.Signer, cmd string) error {
+	conn, err := dialSSH(probe, signer)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return errors.Wrap(err, "failed to open SSH session")
+	}
+	defer session.Close()
+
+	return session.Run(cmd)
+}
+
+// sshSignerForSecret loads the "ssh-privatekey" data key out of the
+// referenced Secret and parses it into an ssh.Signer.
+func sshSignerForSecret(ctx context.Context, c client.Client, ref v1.SecretReference) (ssh.Signer, error) {
+	secret := &v1.Secret{}
+	key := client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}
+	if err := c.Get(ctx, key, secret); err != nil {
+		return nil, errors.Wrapf(err, "failed to get SSH private key secret %s", key)
+	}
+
+	pemBytes, ok := secret.Data["ssh-privatekey"]
+	if !ok {
+		return nil, errors.Errorf("secret %s has no ssh-privatekey data key", key)
+	}
+
+	signer, err := ssh.ParsePrivateKey(pemBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse SSH private key")
+	}
+	return signer, nil
+}
+
+// probeBootstrapSentinel dials probe over SSH and checks that
+// bootstrapSentinelFile exists on the remote host.
+func probeBootstrapSentinel(probe SSHProbe, signer ssh.Signer) error {
+	if err := runSSHCommand(probe, signer, fmt.Sprintf("test -f %s", bootstrapSentinelFile)); err != nil {
+		return errors.Wrapf(err, "bootstrap sentinel file %s not yet present on %s", bootstrapSentinelFile, probe.Address)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Disruptor deliberately breaks the Machine backing node so
+// AssertRemediation can exercise the MachineHealthCheck remediation path.
+type Disruptor interface {
+	// Disrupt breaks machine/node so it stops reporting healthy.
+	Disrupt(ctx context.Context, mgmt ManagementCluster, machine *clusterv1.Machine, node Node) error
+	fmt.Stringer
+}
+
+// RemediationScenario defines the dependencies AssertRemediation needs to
+// break a Machine and verify the MachineHealthCheck remediation path
+// replaces it.
+type RemediationScenario struct {
+	// Disruptor breaks the target Machine. One of
+	// CordonDrainDeleteNodeDisruptor, StopKubeletDisruptor, or
+	// DeleteInfraMachineDisruptor.
+	Disruptor Disruptor
+
+	// MachineHealthCheck is created by AssertRemediation before disrupting
+	// the target Machine. Its Spec.Selector must match the target Machine.
+	MachineHealthCheck *clusterv1.MachineHealthCheck
+}
+
+// CordonDrainDeleteNodeDisruptor cordons and drains the workload Node, then
+// deletes it, simulating an operator-initiated node replacement.
+type CordonDrainDeleteNodeDisruptor struct{}
+
+func (CordonDrainDeleteNodeDisruptor) String() string { return "cordon, drain, and delete the Node" }
+
+func (CordonDrainDeleteNodeDisruptor) Disrupt(ctx context.Context, mgmt ManagementCluster, machine *clusterv1.Machine, node Node) error {
+	mgmtClient, err := mgmt.GetClient()
+	if err != nil {
+		return errors.Wrap(err, "failed to get management client")
+	}
+	cluster := &clusterv1.Cluster{}
+	if err := mgmtClient.Get(ctx, client.ObjectKey{Namespace: machine.Namespace, Name: machine.Spec.ClusterName}, cluster); err != nil {
+		return errors.Wrap(err, "failed to get Cluster for Machine")
+	}
+	workloadClient, err := mgmt.GetWorkloadClient(ctx, cluster.Namespace, cluster.Name)
+	if err != nil {
+		return errors.Wrap(err, "failed to get workload client")
+	}
+
+	n := &v1.Node{}
+	if err := workloadClient.Get(ctx, client.ObjectKey{Name: machine.Status.NodeRef.Name}, n); err != nil {
+		return errors.Wrap(err, "failed to get workload Node")
+	}
+
+	n.Spec.Unschedulable = true
+	if err := workloadClient.Update(ctx, n); err != nil {
+		return errors.Wrap(err, "failed to cordon Node")
+	}
+
+	podList := &v1.PodList{}
+	if err := workloadClient.List(ctx, podList, client.MatchingFields{"spec.nodeName": n.Name}); err != nil {
+		return errors.Wrap(err, "failed to list Pods on Node")
+	}
+	for i := range podList.Items {
+		if err := workloadClient.Delete(ctx, &podList.Items[i]); err != nil && !apierrorsIsNotFound(err) {
+			return errors.Wrapf(err, "failed to evict pod %s", podList.Items[i].Name)
+		}
+	}
+
+	return workloadClient.Delete(ctx, n)
+}
+
+// StopKubeletDisruptor stops kubelet on the target host over SSH, which is
+// the only viable disruption for a BareMetalNodeProfile Node that the
+// framework can't simply delete out from under.
+type StopKubeletDisruptor struct{}
+
+func (StopKubeletDisruptor) String() string { return "stop kubelet over SSH" }
+
+func (StopKubeletDisruptor) Disrupt(ctx context.Context, mgmt ManagementCluster, machine *clusterv1.Machine, node Node) error {
+	mgmtClient, err := mgmt.GetClient()
+	if err != nil {
+		return errors.Wrap(err, "failed to get management client")
+	}
+	signer, err := sshSignerForSecret(ctx, mgmtClient, node.SSHProbe.PrivateKeySecretRef)
+	if err != nil {
+		return err
+	}
+	return runSSHCommand(node.SSHProbe, signer, "systemctl stop kubelet")
+}
+
+// DeleteInfraMachineDisruptor deletes the InfraMachine backing machine,
+// simulating loss of the underlying cloud instance.
+type DeleteInfraMachineDisruptor struct{}
+
+func (DeleteInfraMachineDisruptor) String() string { return "delete the InfraMachine" }
+
+func (DeleteInfraMachineDisruptor) Disrupt(ctx context.Context, mgmt ManagementCluster, machine *clusterv1.Machine, node Node) error {
+	mgmtClient, err := mgmt.GetClient()
+	if err != nil {
+		return errors.Wrap(err, "failed to get management client")
+	}
+	return mgmtClient.Delete(ctx, node.InfraMachine)
+}
+
+// AssertRemediation breaks the Machine backing node using
+// input.RemediationScenario.Disruptor, then asserts that:
+//   - the MachineHealthCheck marks the Machine Unhealthy
+//   - KCP (or the owning MachineDeployment) replaces it with a Machine that
+//     has a different UID and name
+//   - the workload cluster returns to the original replica count within
+//     input.CreateTimeout
+//   - for control-plane remediations, etcd membership is reconciled back to
+//     KCP's replica count
+func (input *ControlplaneClusterInput) AssertRemediation(ctx context.Context, node Node) {
+	input.SetDefaults()
+	Expect(input.RemediationScenario.Disruptor).ToNot(BeNil())
+
+	mgmtClient, err := input.Management.GetClient()
+	Expect(err).NotTo(HaveOccurred(), "stack: %+v", err)
+
+	machine := node.Machine
+	originalUID := machine.UID
+	originalName := machine.Name
+	_, isControlPlaneMachine := machine.Labels[clusterv1.MachineControlPlaneLabelName]
+
+	// Machines that exist before the disruption can't be the replacement.
+	existingUIDs := map[types.UID]bool{}
+	existing := &clusterv1.MachineList{}
+	Expect(mgmtClient.List(ctx, existing, client.InNamespace(machine.Namespace), client.MatchingLabels{clusterv1.ClusterLabelName: input.Cluster.Name})).To(Succeed())
+	for i := range existing.Items {
+		existingUIDs[existing.Items[i].UID] = true
+	}
+
+	By("creating a MachineHealthCheck to drive remediation")
+	Expect(mgmtClient.Create(ctx, input.RemediationScenario.MachineHealthCheck)).To(Succeed())
+
+	By(fmt.Sprintf("disrupting machine %s: %s", originalName, input.RemediationScenario.Disruptor))
+	Expect(input.RemediationScenario.Disruptor.Disrupt(ctx, input.Management, machine, node)).To(Succeed())
+
+	By(fmt.Sprintf("waiting for MachineHealthCheck to mark machine %s Unhealthy", originalName))
+	Eventually(func() (bool, error) {
+		m := &clusterv1.Machine{}
+		if err := mgmtClient.Get(ctx, client.ObjectKey{Namespace: machine.Namespace, Name: originalName}, m); err != nil {
+			return false, err
+		}
+		return conditionFalse(m.Status.Conditions, clusterv1.MachineHealthCheckSucceededCondition), nil
+	}, input.CreateTimeout, eventuallyInterval).Should(BeTrue())
+
+	By("waiting for the unhealthy machine to be replaced")
+	var replacement *clusterv1.Machine
+	Eventually(func() (bool, error) {
+		machines := &clusterv1.MachineList{}
+		if err := mgmtClient.List(ctx, machines, client.InNamespace(machine.Namespace), client.MatchingLabels{clusterv1.ClusterLabelName: input.Cluster.Name}); err != nil {
+			return false, err
+		}
+		for i := range machines.Items {
+			candidate := &machines.Items[i]
+			_, candidateIsControlPlane := candidate.Labels[clusterv1.MachineControlPlaneLabelName]
+			if candidate.UID != originalUID && !existingUIDs[candidate.UID] && candidateIsControlPlane == isControlPlaneMachine {
+				replacement = candidate
+				return true, nil
+			}
+		}
+		return false, nil
+	}, input.CreateTimeout, eventuallyInterval).Should(BeTrue())
+	Expect(replacement.Name).NotTo(Equal(originalName), "replacement machine must have a different name")
+	Expect(replacement.UID).NotTo(Equal(originalUID), "replacement machine must have a different UID")
+
+	By("waiting for the workload cluster to return to the original replica count")
+	expectedReplicas := int(*input.ControlPlane.Spec.Replicas)
+	if md := input.MachineDeployment.MachineDeployment; md != nil && md.Spec.Replicas != nil {
+		expectedReplicas += int(*md.Spec.Replicas)
+	}
+	Eventually(func() (int, error) {
+		workloadClient, err := input.Management.GetWorkloadClient(ctx, input.Cluster.Namespace, input.Cluster.Name)
+		if err != nil {
+			return -1, errors.Wrap(err, "failed to get workload client")
+		}
+		nodeList := v1.NodeList{}
+		if err := workloadClient.List(ctx, &nodeList); err != nil {
+			return -1, err
+		}
+		return len(nodeList.Items), nil
+	}, input.CreateTimeout, eventuallyInterval).Should(Equal(expectedReplicas))
+
+	if isControlPlaneMachine {
+		By("waiting for etcd membership to be reconciled to KCP's replica count")
+		Eventually(func() (int, error) {
+			return etcdMemberCount(ctx, input.Management, input.Cluster.Namespace, input.Cluster.Name)
+		}, input.CreateTimeout, eventuallyInterval).Should(Equal(int(*input.ControlPlane.Spec.Replicas)))
+	}
+}
+
+// conditionFalse reports whether conditions has condType set to False.
+func conditionFalse(conditions clusterv1.Conditions, condType clusterv1.ConditionType) bool {
+	for _, c := range conditions {
+		if c.Type == condType {
+			return c.Status == v1.ConditionFalse
+		}
+	}
+	return false
+}
+
+// etcdMemberCount counts the etcd member Pods in kube-system on the
+// workload cluster, used as a proxy for etcd cluster membership since the
+// framework doesn't carry a full etcd client.
+func etcdMemberCount(ctx context.Context, mgmt ManagementCluster, namespace, name string) (int, error) {
+	workloadClient, err := mgmt.GetWorkloadClient(ctx, namespace, name)
+	if err != nil {
+		return -1, errors.Wrap(err, "failed to get workload client")
+	}
+	podList := &v1.PodList{}
+	if err := workloadClient.List(ctx, podList, client.InNamespace("kube-system"), client.MatchingLabels{"component": "etcd"}); err != nil {
+		return -1, err
+	}
+	return len(podList.Items), nil
+}
+
+func apierrorsIsNotFound(err error) bool {
+	return client.IgnoreNotFound(err) == nil
+}
@@ -0,0 +1,363 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	cabpkv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	pb "sigs.k8s.io/cluster-api/test/framework/management/v1alpha1"
+)
+
+// remoteManagementScheme is used only to derive GroupVersionKinds for the
+// concrete CAPI/core types the framework deals with; RemoteManagement never
+// needs a live RESTMapper since the wire format is JSON, not protobuf.
+var remoteManagementScheme = func() *runtime.Scheme {
+	s := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(s)
+	_ = clusterv1.AddToScheme(s)
+	_ = controlplanev1.AddToScheme(s)
+	_ = cabpkv1.AddToScheme(s)
+	return s
+}()
+
+// RemoteManagement is a ManagementCluster implementation that speaks to a
+// long-lived CAPI controller over gRPC instead of holding a kubeconfig
+// in-process. This lets ControlPlaneCluster/CleanUpCoreArtifacts run as a
+// thin driver against a management cluster sitting behind a bastion or in a
+// different network than the test binary.
+type RemoteManagement struct {
+	// Addr is the gRPC address of the RemoteManagement server, e.g.
+	// "bastion.example.com:8443".
+	Addr string
+
+	// DialOptions are passed to grpc.Dial verbatim, e.g. transport
+	// credentials for the bastion hop.
+	DialOptions []grpc.DialOption
+
+	conn *grpc.ClientConn
+}
+
+var _ ManagementCluster = &RemoteManagement{}
+
+// GetClient dials Addr (if not already connected) and returns a
+// controller-runtime client.Client backed by the gRPC service. The returned
+// client satisfies the Lister/Getter/Creator interfaces ensureArtifactsDeleted
+// depends on, so existing assertions work unchanged.
+func (r *RemoteManagement) GetClient() (client.Client, error) {
+	conn, err := r.dial()
+	if err != nil {
+		return nil, err
+	}
+	return &remoteClient{svc: pb.NewManagementClusterClient(conn)}, nil
+}
+
+// GetWorkloadClient streams the workload cluster's kubeconfig from the
+// server and builds a client.Client from it.
+func (r *RemoteManagement) GetWorkloadClient(ctx context.Context, namespace, name string) (client.Client, error) {
+	conn, err := r.dial()
+	if err != nil {
+		return nil, err
+	}
+	svc := pb.NewManagementClusterClient(conn)
+
+	stream, err := svc.GetWorkloadClient(ctx, &pb.WorkloadClientRequest{Namespace: namespace, Name: name})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open GetWorkloadClient stream")
+	}
+
+	var buf bytes.Buffer
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to stream workload kubeconfig")
+		}
+		buf.Write(chunk.Data)
+	}
+
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(buf.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build rest.Config from streamed kubeconfig")
+	}
+	return client.New(restConfig, client.Options{Scheme: remoteManagementScheme})
+}
+
+func (r *RemoteManagement) dial() (*grpc.ClientConn, error) {
+	if r.conn != nil {
+		return r.conn, nil
+	}
+	conn, err := grpc.Dial(r.Addr, r.DialOptions...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dial RemoteManagement server at %s", r.Addr)
+	}
+	r.conn = conn
+	return conn, nil
+}
+
+// remoteClient adapts pb.ManagementClusterClient to controller-runtime's
+// client.WithWatch so it can be handed to code, such as ensureArtifactsDeleted
+// and waitfor.For, that only knows about the local interface.
+type remoteClient struct {
+	svc pb.ManagementClusterClient
+}
+
+var _ client.WithWatch = &remoteClient{}
+
+func (c *remoteClient) Create(ctx context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+	pbObj, err := toPBObject(obj)
+	if err != nil {
+		return err
+	}
+	_, err = c.svc.Create(ctx, pbObj)
+	return err
+}
+
+func (c *remoteClient) Delete(ctx context.Context, obj runtime.Object, _ ...client.DeleteOption) error {
+	pbObj, err := toPBObject(obj)
+	if err != nil {
+		return err
+	}
+	_, err = c.svc.Delete(ctx, pbObj)
+	return err
+}
+
+func (c *remoteClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, remoteManagementScheme)
+	if err != nil {
+		return err
+	}
+	resp, err := c.svc.Get(ctx, &pb.GetRequest{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  key.Namespace,
+		Name:       key.Name,
+	})
+	if err != nil {
+		return err
+	}
+	if resp == nil || len(resp.JSON) == 0 {
+		return apierrors.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, key.Name)
+	}
+	return json.Unmarshal(resp.JSON, obj)
+}
+
+func (c *remoteClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+
+	gvk, err := apiutil.GVKForObject(list, remoteManagementScheme)
+	if err != nil {
+		return err
+	}
+	// Lists carry a "List"-suffixed kind; the item kind is what the server needs.
+	itemKind := gvk.Kind
+	if len(itemKind) > 4 && itemKind[len(itemKind)-4:] == "List" {
+		itemKind = itemKind[:len(itemKind)-4]
+	}
+
+	req := &pb.ListRequest{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       itemKind,
+		Namespace:  listOpts.Namespace,
+	}
+	if listOpts.LabelSelector != nil {
+		req.LabelSelector = map[string]string{}
+		reqs, _ := listOpts.LabelSelector.Requirements()
+		for _, r := range reqs {
+			if vals := r.Values().List(); len(vals) > 0 {
+				req.LabelSelector[r.Key()] = vals[0]
+			}
+		}
+	}
+
+	resp, err := c.svc.List(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	items := make([]unstructured.Unstructured, 0, len(resp.Items))
+	for _, item := range resp.Items {
+		u := unstructured.Unstructured{}
+		if err := json.Unmarshal(item.JSON, &u.Object); err != nil {
+			return errors.Wrap(err, "failed to decode list item")
+		}
+		items = append(items, u)
+	}
+
+	ul := &unstructured.UnstructuredList{Items: items}
+	buf, err := json.Marshal(ul)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, list)
+}
+
+// Watch opens a server-streaming Watch RPC and adapts the resulting
+// pb.WatchEvent stream into a client-go watch.Interface, so remoteClient
+// satisfies client.WithWatch for callers such as waitfor.For.
+func (c *remoteClient) Watch(ctx context.Context, list runtime.Object, opts ...client.ListOption) (watch.Interface, error) {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+
+	gvk, err := apiutil.GVKForObject(list, remoteManagementScheme)
+	if err != nil {
+		return nil, err
+	}
+	// Lists carry a "List"-suffixed kind; the item kind is what the server needs.
+	itemKind := gvk.Kind
+	if len(itemKind) > 4 && itemKind[len(itemKind)-4:] == "List" {
+		itemKind = itemKind[:len(itemKind)-4]
+	}
+
+	req := &pb.ListRequest{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       itemKind,
+		Namespace:  listOpts.Namespace,
+	}
+	if listOpts.LabelSelector != nil {
+		req.LabelSelector = map[string]string{}
+		reqs, _ := listOpts.LabelSelector.Requirements()
+		for _, r := range reqs {
+			if vals := r.Values().List(); len(vals) > 0 {
+				req.LabelSelector[r.Key()] = vals[0]
+			}
+		}
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	stream, err := c.svc.Watch(watchCtx, req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	w := &remoteWatch{cancel: cancel, events: make(chan watch.Event)}
+	go w.run(watchCtx, stream)
+	return w, nil
+}
+
+// remoteWatch adapts a pb.ManagementCluster_WatchClient stream to
+// watch.Interface: Stop cancels the context the stream was opened with,
+// which causes the server to close the stream and run's Recv loop to exit.
+type remoteWatch struct {
+	cancel context.CancelFunc
+	events chan watch.Event
+}
+
+func (w *remoteWatch) Stop() {
+	w.cancel()
+}
+
+func (w *remoteWatch) ResultChan() <-chan watch.Event {
+	return w.events
+}
+
+func (w *remoteWatch) run(ctx context.Context, stream pb.ManagementCluster_WatchClient) {
+	defer close(w.events)
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return
+		}
+
+		var eventType watch.EventType
+		switch event.Type {
+		case pb.WatchEventType_ADDED:
+			eventType = watch.Added
+		case pb.WatchEventType_MODIFIED:
+			eventType = watch.Modified
+		case pb.WatchEventType_DELETED:
+			eventType = watch.Deleted
+		default:
+			continue
+		}
+
+		u := &unstructured.Unstructured{}
+		if event.Object != nil {
+			if err := json.Unmarshal(event.Object.JSON, &u.Object); err != nil {
+				continue
+			}
+		}
+
+		select {
+		case w.events <- watch.Event{Type: eventType, Object: u}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *remoteClient) Update(_ context.Context, _ runtime.Object, _ ...client.UpdateOption) error {
+	return errors.New("RemoteManagement client does not yet support Update; use Create/Delete")
+}
+
+func (c *remoteClient) Patch(_ context.Context, _ runtime.Object, _ client.Patch, _ ...client.PatchOption) error {
+	return errors.New("RemoteManagement client does not yet support Patch")
+}
+
+func (c *remoteClient) DeleteAllOf(_ context.Context, _ runtime.Object, _ ...client.DeleteAllOfOption) error {
+	return errors.New("RemoteManagement client does not yet support DeleteAllOf")
+}
+
+func (c *remoteClient) Status() client.StatusWriter {
+	return c
+}
+
+func (c *remoteClient) Scheme() *runtime.Scheme {
+	return remoteManagementScheme
+}
+
+func (c *remoteClient) RESTMapper() meta.RESTMapper {
+	return nil
+}
+
+func toPBObject(obj runtime.Object) (*pb.Object, error) {
+	gvk, err := apiutil.GVKForObject(obj, remoteManagementScheme)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode object")
+	}
+	return &pb.Object{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		JSON:       raw,
+	}, nil
+}
@@ -0,0 +1,315 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package waitfor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// decode converts the unstructured object carried by a watch.Event into out.
+func decode(event watch.Event, out interface{}) error {
+	u, ok := event.Object.(*unstructured.Unstructured)
+	if !ok {
+		return errors.Errorf("unexpected event object type %T", event.Object)
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, out)
+}
+
+type clusterPhaseCondition struct {
+	key   types.NamespacedName
+	phase clusterv1.ClusterPhase
+}
+
+// ClusterPhase is satisfied once the named Cluster's Status.Phase equals
+// phase, e.g. ClusterPhase(key, clusterv1.ClusterPhaseProvisioned).
+func ClusterPhase(key types.NamespacedName, phase clusterv1.ClusterPhase) Condition {
+	return &clusterPhaseCondition{key: key, phase: phase}
+}
+
+func (c *clusterPhaseCondition) GroupVersionKind() schema.GroupVersionKind {
+	return clusterv1.GroupVersion.WithKind("Cluster")
+}
+
+func (c *clusterPhaseCondition) ListOptions() []client.ListOption {
+	return []client.ListOption{client.InNamespace(c.key.Namespace)}
+}
+
+func (c *clusterPhaseCondition) Matches(event watch.Event) (bool, error) {
+	if event.Type == watch.Bookmark {
+		return false, nil
+	}
+	cluster := &clusterv1.Cluster{}
+	if err := decode(event, cluster); err != nil {
+		return false, err
+	}
+	if cluster.Name != c.key.Name {
+		return false, nil
+	}
+	return cluster.Status.Phase == string(c.phase), nil
+}
+
+func (c *clusterPhaseCondition) String() string {
+	return fmt.Sprintf("Cluster %s to reach phase %s", c.key, c.phase)
+}
+
+type clusterDeletedCondition struct {
+	key types.NamespacedName
+
+	// seen records whether the named Cluster has ever been observed to
+	// exist, so a Bookmark (the informer's "initial snapshot fully
+	// replayed" signal) can tell "it's not in the snapshot because it was
+	// already deleted before we subscribed" apart from "it doesn't exist
+	// yet and the Bookmark just arrived first".
+	seen bool
+}
+
+// ClusterDeleted is satisfied once the named Cluster has been deleted.
+func ClusterDeleted(key types.NamespacedName) Condition {
+	return &clusterDeletedCondition{key: key}
+}
+
+func (c *clusterDeletedCondition) GroupVersionKind() schema.GroupVersionKind {
+	return clusterv1.GroupVersion.WithKind("Cluster")
+}
+
+func (c *clusterDeletedCondition) ListOptions() []client.ListOption {
+	return []client.ListOption{client.InNamespace(c.key.Namespace)}
+}
+
+func (c *clusterDeletedCondition) Matches(event watch.Event) (bool, error) {
+	if event.Type == watch.Bookmark {
+		return !c.seen, nil
+	}
+	cluster := &clusterv1.Cluster{}
+	if err := decode(event, cluster); err != nil {
+		return false, err
+	}
+	if cluster.Name != c.key.Name {
+		return false, nil
+	}
+	if event.Type == watch.Deleted {
+		return true, nil
+	}
+	c.seen = true
+	return false, nil
+}
+
+func (c *clusterDeletedCondition) String() string {
+	return fmt.Sprintf("Cluster %s to be deleted", c.key)
+}
+
+type kcpInitializedCondition struct {
+	key types.NamespacedName
+}
+
+// KCPInitialized is satisfied once the named KubeadmControlPlane reports
+// Status.Initialized.
+func KCPInitialized(key types.NamespacedName) Condition {
+	return &kcpInitializedCondition{key: key}
+}
+
+func (c *kcpInitializedCondition) GroupVersionKind() schema.GroupVersionKind {
+	return controlplanev1.GroupVersion.WithKind("KubeadmControlPlane")
+}
+
+func (c *kcpInitializedCondition) ListOptions() []client.ListOption {
+	return []client.ListOption{client.InNamespace(c.key.Namespace)}
+}
+
+func (c *kcpInitializedCondition) Matches(event watch.Event) (bool, error) {
+	if event.Type == watch.Bookmark {
+		return false, nil
+	}
+	kcp := &controlplanev1.KubeadmControlPlane{}
+	if err := decode(event, kcp); err != nil {
+		return false, err
+	}
+	if kcp.Name != c.key.Name {
+		return false, nil
+	}
+	return kcp.Status.Initialized, nil
+}
+
+func (c *kcpInitializedCondition) String() string {
+	return fmt.Sprintf("KubeadmControlPlane %s to be initialized", c.key)
+}
+
+type machinesRunningCondition struct {
+	namespace string
+	selector  labels.Selector
+	n         int
+
+	phaseByName map[string]string
+}
+
+// MachinesRunning is satisfied once n Machines matching selector in
+// namespace all report Status.Phase == Running.
+func MachinesRunning(namespace string, selector labels.Selector, n int) Condition {
+	return &machinesRunningCondition{namespace: namespace, selector: selector, n: n, phaseByName: map[string]string{}}
+}
+
+func (c *machinesRunningCondition) GroupVersionKind() schema.GroupVersionKind {
+	return clusterv1.GroupVersion.WithKind("Machine")
+}
+
+func (c *machinesRunningCondition) ListOptions() []client.ListOption {
+	return []client.ListOption{client.InNamespace(c.namespace), &client.ListOptions{LabelSelector: c.selector}}
+}
+
+func (c *machinesRunningCondition) Matches(event watch.Event) (bool, error) {
+	if event.Type == watch.Bookmark {
+		return false, nil
+	}
+	machine := &clusterv1.Machine{}
+	if err := decode(event, machine); err != nil {
+		return false, err
+	}
+
+	switch event.Type {
+	case watch.Deleted:
+		delete(c.phaseByName, machine.Name)
+	default:
+		c.phaseByName[machine.Name] = machine.Status.Phase
+	}
+
+	if len(c.phaseByName) < c.n {
+		return false, nil
+	}
+	running := 0
+	for _, phase := range c.phaseByName {
+		if phase == string(clusterv1.MachinePhaseRunning) {
+			running++
+		}
+	}
+	return running >= c.n, nil
+}
+
+func (c *machinesRunningCondition) String() string {
+	return fmt.Sprintf("%d Machines in namespace %s matching %s to be Running", c.n, c.namespace, c.selector)
+}
+
+type workloadNodesReadyCondition struct {
+	namespace string
+	name      string
+	n         int
+}
+
+// WorkloadNodesReady is satisfied once n Nodes exist in the workload
+// cluster identified by namespace/name. Unlike the other conditions, this
+// watches the workload cluster (via ManagementCluster.GetWorkloadClient),
+// not the management cluster, so it opens its own watch rather than
+// sharing the per-ManagementCluster informer.
+func WorkloadNodesReady(namespace, name string, n int) Condition {
+	return &workloadNodesReadyCondition{namespace: namespace, name: name, n: n}
+}
+
+func (c *workloadNodesReadyCondition) GroupVersionKind() schema.GroupVersionKind { return schema.GroupVersionKind{} }
+func (c *workloadNodesReadyCondition) ListOptions() []client.ListOption          { return nil }
+
+func (c *workloadNodesReadyCondition) Matches(event watch.Event) (bool, error) {
+	nodeList, ok := event.Object.(*v1.NodeList)
+	if !ok {
+		return false, errors.Errorf("unexpected event object type %T", event.Object)
+	}
+	return len(nodeList.Items) >= c.n, nil
+}
+
+func (c *workloadNodesReadyCondition) String() string {
+	return fmt.Sprintf("%d Nodes to exist in workload cluster %s/%s", c.n, c.namespace, c.name)
+}
+
+// watch implements selfWatching by polling the workload cluster's Node list
+// on a short interval and re-emitting it as a synthetic watch.Event; the
+// workload cluster's own watch endpoint may not be reachable until its
+// control plane is up, which is exactly the condition being waited on.
+func (c *workloadNodesReadyCondition) watch(ctx context.Context, mgmt ManagementCluster) (<-chan watch.Event, error) {
+	events := make(chan watch.Event, 1)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				workloadClient, err := mgmt.GetWorkloadClient(ctx, c.namespace, c.name)
+				if err != nil {
+					continue
+				}
+				nodeList := &v1.NodeList{}
+				if err := workloadClient.List(ctx, nodeList); err != nil {
+					continue
+				}
+				select {
+				case events <- watch.Event{Type: watch.Modified, Object: nodeList}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+type artifactsDeletedCondition struct {
+	namespace string
+	selector  labels.Selector
+}
+
+// ArtifactsDeleted fires once a Machine matching selector in namespace is
+// observed being deleted. It is a liveness signal for "the cluster is being
+// torn down", not an exhaustive check: it does not assert that every
+// matching Machine, or any other owned kind, is gone.
+func ArtifactsDeleted(namespace string, selector labels.Selector) Condition {
+	return &artifactsDeletedCondition{namespace: namespace, selector: selector}
+}
+
+func (c *artifactsDeletedCondition) GroupVersionKind() schema.GroupVersionKind {
+	return clusterv1.GroupVersion.WithKind("Machine")
+}
+
+func (c *artifactsDeletedCondition) ListOptions() []client.ListOption {
+	return []client.ListOption{client.InNamespace(c.namespace), &client.ListOptions{LabelSelector: c.selector}}
+}
+
+func (c *artifactsDeletedCondition) Matches(event watch.Event) (bool, error) {
+	if event.Type != watch.Deleted {
+		return false, nil
+	}
+	// A single Deleted Machine event is only a proxy for "the cluster is
+	// being torn down"; Machines that remain are not checked.
+	return true, nil
+}
+
+func (c *artifactsDeletedCondition) String() string {
+	return fmt.Sprintf("a Machine in namespace %s matching %s to be deleted", c.namespace, c.selector)
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package waitfor
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+)
+
+func toUnstructuredEvent(t *testing.T, eventType watch.EventType, obj runtime.Object) watch.Event {
+	t.Helper()
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		t.Fatalf("failed to convert %T to unstructured: %v", obj, err)
+	}
+	return watch.Event{Type: eventType, Object: &unstructured.Unstructured{Object: m}}
+}
+
+func TestClusterPhaseCondition(t *testing.T) {
+	g := NewWithT(t)
+	key := types.NamespacedName{Namespace: "ns", Name: "cluster1"}
+	cond := ClusterPhase(key, clusterv1.ClusterPhaseProvisioned)
+
+	other := &clusterv1.Cluster{}
+	other.Name = "other-cluster"
+	other.Status.Phase = string(clusterv1.ClusterPhaseProvisioned)
+	ok, err := cond.Matches(toUnstructuredEvent(t, watch.Added, other))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse(), "events for a differently-named Cluster must not match")
+
+	notYet := &clusterv1.Cluster{}
+	notYet.Name = key.Name
+	notYet.Status.Phase = string(clusterv1.ClusterPhasePending)
+	ok, err = cond.Matches(toUnstructuredEvent(t, watch.Added, notYet))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+
+	// This is the scenario the seeded-List fix exists for: the Cluster was
+	// already Provisioned by the time For() subscribed, so the only event
+	// the condition ever sees for it is the informer's synthetic Added from
+	// the initial List, not a live Modified.
+	alreadyProvisioned := &clusterv1.Cluster{}
+	alreadyProvisioned.Name = key.Name
+	alreadyProvisioned.Status.Phase = string(clusterv1.ClusterPhaseProvisioned)
+	ok, err = cond.Matches(toUnstructuredEvent(t, watch.Added, alreadyProvisioned))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+}
+
+func TestClusterPhaseConditionIgnoresBookmark(t *testing.T) {
+	g := NewWithT(t)
+	cond := ClusterPhase(types.NamespacedName{Namespace: "ns", Name: "cluster1"}, clusterv1.ClusterPhaseProvisioned)
+	ok, err := cond.Matches(watch.Event{Type: watch.Bookmark})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestClusterDeletedCondition(t *testing.T) {
+	key := types.NamespacedName{Namespace: "ns", Name: "cluster1"}
+
+	t.Run("fires on a live Deleted event", func(t *testing.T) {
+		g := NewWithT(t)
+		cond := ClusterDeleted(key)
+
+		cluster := &clusterv1.Cluster{}
+		cluster.Name = key.Name
+		ok, err := cond.Matches(toUnstructuredEvent(t, watch.Added, cluster))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+
+		ok, err = cond.Matches(toUnstructuredEvent(t, watch.Deleted, cluster))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+	})
+
+	t.Run("a Bookmark after seeing the object live is not enough on its own", func(t *testing.T) {
+		g := NewWithT(t)
+		cond := ClusterDeleted(key)
+
+		cluster := &clusterv1.Cluster{}
+		cluster.Name = key.Name
+		_, err := cond.Matches(toUnstructuredEvent(t, watch.Added, cluster))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		ok, err := cond.Matches(watch.Event{Type: watch.Bookmark})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+	})
+
+	t.Run("a Bookmark with the object never observed means it was already deleted", func(t *testing.T) {
+		g := NewWithT(t)
+		cond := ClusterDeleted(key)
+
+		// The informer's initial List came back without this Cluster at
+		// all, e.g. because it was deleted between the caller's Delete and
+		// For() subscribing. The Bookmark signals "initial snapshot fully
+		// replayed" with nothing ever dispatched for this key.
+		ok, err := cond.Matches(watch.Event{Type: watch.Bookmark})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+	})
+}
+
+func TestKCPInitializedCondition(t *testing.T) {
+	g := NewWithT(t)
+	key := types.NamespacedName{Namespace: "ns", Name: "kcp1"}
+	cond := KCPInitialized(key)
+
+	kcp := &controlplanev1.KubeadmControlPlane{}
+	kcp.Name = key.Name
+	kcp.Status.Initialized = true
+
+	// Seeded from an initial List rather than a live watch event: the
+	// condition must still match.
+	ok, err := cond.Matches(toUnstructuredEvent(t, watch.Added, kcp))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+
+	ok, err = cond.Matches(watch.Event{Type: watch.Bookmark})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestMachinesRunningCondition(t *testing.T) {
+	g := NewWithT(t)
+	cond := MachinesRunning("ns", nil, 2)
+
+	running := func(name string) *clusterv1.Machine {
+		m := &clusterv1.Machine{}
+		m.Name = name
+		m.Status.Phase = string(clusterv1.MachinePhaseRunning)
+		return m
+	}
+
+	ok, err := cond.Matches(toUnstructuredEvent(t, watch.Added, running("m1")))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse(), "only 1 of 2 required Machines seen so far")
+
+	ok, err = cond.Matches(toUnstructuredEvent(t, watch.Added, running("m2")))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+
+	// A Machine being deleted after the condition already matched drops the
+	// running count back below the threshold.
+	ok, err = cond.Matches(toUnstructuredEvent(t, watch.Deleted, running("m1")))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package waitfor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// informerRegistry holds the single Informer for each ManagementCluster
+// that For has been called against, so repeated calls (e.g. the several in
+// ControlPlaneCluster) reuse the same Informer and client. Watches are shared
+// only among Conditions subscribed at the same time; For unsubscribes when it
+// returns, so a later call opens its watches afresh.
+var (
+	informerRegistry   = map[ManagementCluster]*Informer{}
+	informerRegistryMu sync.Mutex
+)
+
+func sharedInformer(mgmt ManagementCluster) (*Informer, error) {
+	informerRegistryMu.Lock()
+	defer informerRegistryMu.Unlock()
+
+	if i, ok := informerRegistry[mgmt]; ok {
+		return i, nil
+	}
+
+	c, err := mgmt.GetClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get client for informer")
+	}
+	watchClient, ok := c.(client.WithWatch)
+	if !ok {
+		return nil, errors.New("ManagementCluster client does not support Watch")
+	}
+
+	i := &Informer{client: watchClient, watches: map[string]*watchEntry{}}
+	informerRegistry[mgmt] = i
+	return i, nil
+}
+
+// Informer multiplexes any number of Conditions across as few underlying
+// watch.Interface streams as possible: one per distinct
+// (GroupVersionKind, namespace, label selector) tuple, shared by all current
+// subscribers to that tuple and stopped once the last of them unsubscribes.
+type Informer struct {
+	client client.WithWatch
+
+	mu      sync.Mutex
+	watches map[string]*watchEntry
+}
+
+type watchEntry struct {
+	cancel      context.CancelFunc
+	subscribers map[int]func(watch.Event)
+	nextID      int
+}
+
+// subscribe registers callback against the watch for (gvk, opts), starting
+// it if it doesn't already exist, and returns a function that removes the
+// subscription (and stops the underlying watch once nobody is left using
+// it).
+func (i *Informer) subscribe(gvk schema.GroupVersionKind, opts []client.ListOption, callback func(watch.Event)) func() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	key := watchKey(gvk, opts)
+	entry, ok := i.watches[key]
+	if !ok {
+		entry = &watchEntry{subscribers: map[int]func(watch.Event){}}
+		i.watches[key] = entry
+		i.startWatch(gvk, opts, key, entry)
+	}
+
+	id := entry.nextID
+	entry.nextID++
+	entry.subscribers[id] = callback
+
+	return func() {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		delete(entry.subscribers, id)
+		if len(entry.subscribers) == 0 {
+			entry.cancel()
+			delete(i.watches, key)
+		}
+	}
+}
+
+func (i *Informer) startWatch(gvk schema.GroupVersionKind, opts []client.ListOption, key string, entry *watchEntry) {
+	ctx, cancel := context.WithCancel(context.Background())
+	entry.cancel = cancel
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind + "List"})
+
+	go func() {
+		// Seed subscribers with whatever already satisfies the condition
+		// before subscribing to the watch itself. Without this, a
+		// condition that became true before For() was ever called (e.g.
+		// the Cluster is already Provisioned) would never see the event
+		// that made it true and would hang until timeout.
+		if err := i.client.List(ctx, list, opts...); err != nil {
+			i.dispatch(key, watch.Event{Type: watch.Error})
+			return
+		}
+		for idx := range list.Items {
+			i.dispatch(key, watch.Event{Type: watch.Added, Object: &list.Items[idx]})
+		}
+		// Signal that the initial snapshot has been fully replayed, so a
+		// Condition that cares about the *absence* of an object (e.g.
+		// ClusterDeleted, when the object was already reaped before For()
+		// subscribed) can tell "not seen yet" apart from "never existed".
+		i.dispatch(key, watch.Event{Type: watch.Bookmark})
+
+		watchOpts := append(append([]client.ListOption{}, opts...), &client.ListOptions{
+			Raw: &metav1.ListOptions{ResourceVersion: list.GetResourceVersion()},
+		})
+		w, err := i.client.Watch(ctx, list, watchOpts...)
+		if err != nil {
+			// Nothing to dispatch the error to but the subscribers; feed it
+			// through as an Error-typed event so Condition.Matches can
+			// surface it via its normal error path.
+			i.dispatch(key, watch.Event{Type: watch.Error})
+			return
+		}
+		defer w.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-w.ResultChan():
+				if !ok {
+					return
+				}
+				i.dispatch(key, event)
+			}
+		}
+	}()
+}
+
+func (i *Informer) dispatch(key string, event watch.Event) {
+	i.mu.Lock()
+	entry, ok := i.watches[key]
+	if !ok {
+		i.mu.Unlock()
+		return
+	}
+	callbacks := make([]func(watch.Event), 0, len(entry.subscribers))
+	for _, cb := range entry.subscribers {
+		callbacks = append(callbacks, cb)
+	}
+	i.mu.Unlock()
+
+	for _, cb := range callbacks {
+		cb(event)
+	}
+}
+
+func watchKey(gvk schema.GroupVersionKind, opts []client.ListOption) string {
+	lo := &client.ListOptions{}
+	lo.ApplyOptions(opts)
+	sel := ""
+	if lo.LabelSelector != nil {
+		sel = lo.LabelSelector.String()
+	}
+	return fmt.Sprintf("%s/%s namespace=%s selector=%s", gvk.GroupVersion(), gvk.Kind, lo.Namespace, sel)
+}
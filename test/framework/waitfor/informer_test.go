@@ -0,0 +1,207 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package waitfor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeWatch is a controllable watch.Interface: tests send events on in and
+// observe Stop() via stopped.
+type fakeWatch struct {
+	in      chan watch.Event
+	mu      sync.Mutex
+	stopped bool
+}
+
+func newFakeWatch() *fakeWatch {
+	return &fakeWatch{in: make(chan watch.Event)}
+}
+
+func (w *fakeWatch) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.in)
+	}
+}
+
+func (w *fakeWatch) ResultChan() <-chan watch.Event {
+	return w.in
+}
+
+func (w *fakeWatch) isStopped() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stopped
+}
+
+// fakeWatchClient is a client.WithWatch that serves a canned List and hands
+// back a fakeWatch per Watch() call, recorded for assertions.
+type fakeWatchClient struct {
+	client.WithWatch
+
+	listItems []unstructured.Unstructured
+
+	mu      sync.Mutex
+	watches []*fakeWatch
+}
+
+func (f *fakeWatchClient) List(_ context.Context, list runtime.Object, _ ...client.ListOption) error {
+	ul := list.(*unstructured.UnstructuredList)
+	ul.Items = append([]unstructured.Unstructured{}, f.listItems...)
+	return nil
+}
+
+func (f *fakeWatchClient) Watch(_ context.Context, _ runtime.Object, _ ...client.ListOption) (watch.Interface, error) {
+	w := newFakeWatch()
+	f.mu.Lock()
+	f.watches = append(f.watches, w)
+	f.mu.Unlock()
+	return w, nil
+}
+
+func (f *fakeWatchClient) lastWatch() *fakeWatch {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.watches) == 0 {
+		return nil
+	}
+	return f.watches[len(f.watches)-1]
+}
+
+func (f *fakeWatchClient) watchCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.watches)
+}
+
+func recvEvent(t *testing.T, events <-chan watch.Event) watch.Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+		return watch.Event{}
+	}
+}
+
+func expectNoEvent(t *testing.T, events <-chan watch.Event) {
+	t.Helper()
+	select {
+	case e := <-events:
+		t.Fatalf("expected no further event, got %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// waitUntil polls cond, which may not observe its effect synchronously
+// (e.g. a cancelled context unwinding a goroutine), until it returns true
+// or timeout elapses.
+func waitUntil(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		if cond() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestInformerSubscribeSeedsFromInitialList(t *testing.T) {
+	g := NewWithT(t)
+
+	existing := &clusterv1.Cluster{}
+	existing.Name = "already-provisioned"
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(existing)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	fake := &fakeWatchClient{listItems: []unstructured.Unstructured{{Object: m}}}
+	informer := &Informer{client: fake, watches: map[string]*watchEntry{}}
+
+	events := make(chan watch.Event, 1)
+	unsubscribe := informer.subscribe(clusterv1.GroupVersion.WithKind("Cluster"), nil, func(e watch.Event) {
+		events <- e
+	})
+	defer unsubscribe()
+
+	// The only object that will ever exist for this condition was already
+	// present before subscribe() was called, so the very first thing the
+	// subscriber sees must be a synthetic Added for it, not silence until a
+	// watch event that will never arrive.
+	first := recvEvent(t, events)
+	g.Expect(first.Type).To(Equal(watch.Added))
+	u, ok := first.Object.(*unstructured.Unstructured)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(u.GetName()).To(Equal("already-provisioned"))
+
+	second := recvEvent(t, events)
+	g.Expect(second.Type).To(Equal(watch.Bookmark))
+}
+
+func TestInformerSharesOneWatchAcrossSubscribers(t *testing.T) {
+	g := NewWithT(t)
+
+	fake := &fakeWatchClient{}
+	informer := &Informer{client: fake, watches: map[string]*watchEntry{}}
+
+	gvk := clusterv1.GroupVersion.WithKind("Cluster")
+	eventsA := make(chan watch.Event, 4)
+	eventsB := make(chan watch.Event, 4)
+
+	unsubA := informer.subscribe(gvk, nil, func(e watch.Event) { eventsA <- e })
+	// The empty-List seed only replays once, against whichever subscriber
+	// triggered entry creation; drain it before B joins the same entry.
+	g.Expect(recvEvent(t, eventsA).Type).To(Equal(watch.Bookmark))
+
+	unsubB := informer.subscribe(gvk, nil, func(e watch.Event) { eventsB <- e })
+	expectNoEvent(t, eventsB)
+
+	g.Expect(fake.watchCount()).To(Equal(1), "subscribers on the same (gvk, opts) key must share one underlying watch")
+
+	w := fake.lastWatch()
+	w.in <- watch.Event{Type: watch.Modified}
+
+	g.Expect(recvEvent(t, eventsA).Type).To(Equal(watch.Modified))
+	g.Expect(recvEvent(t, eventsB).Type).To(Equal(watch.Modified))
+
+	unsubA()
+	g.Expect(w.isStopped()).To(BeFalse(), "the watch must stay open while any subscriber remains")
+
+	w.in <- watch.Event{Type: watch.Deleted}
+	expectNoEvent(t, eventsA)
+	g.Expect(recvEvent(t, eventsB).Type).To(Equal(watch.Deleted))
+
+	unsubB()
+	g.Expect(waitUntil(t, time.Second, w.isStopped)).To(BeTrue(), "the watch must be stopped once the last subscriber unsubscribes")
+}
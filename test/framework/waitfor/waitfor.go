@@ -0,0 +1,205 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package waitfor provides a composable, event-driven replacement for the
+// hand-rolled `Eventually(func() ... , input.CreateTimeout, eventuallyInterval)`
+// polling loops that used to live in framework.ControlPlaneCluster and
+// framework.CleanUpCoreArtifacts. Instead of re-listing/re-getting objects
+// on a fixed interval, a Condition is driven by a controller-runtime Watch
+// shared across all conditions registered against the same
+// ManagementCluster, and failures report the transitions that were actually
+// observed rather than "still not Running after 10m".
+package waitfor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ManagementCluster is the subset of framework.ManagementCluster that
+// waitfor needs. It is declared locally, rather than imported, so that
+// framework can depend on waitfor without creating an import cycle; any
+// framework.ManagementCluster already satisfies it.
+type ManagementCluster interface {
+	GetClient() (client.Client, error)
+	GetWorkloadClient(ctx context.Context, namespace, name string) (client.Client, error)
+}
+
+// Condition observes objects from a watch stream and reports whether it has
+// been satisfied yet. Implementations are expected to be cheap. They may keep
+// state across calls (e.g. MachinesRunning tracks each Machine's phase), so a
+// Condition value serves a single For call; For calls Matches once per event
+// delivered for the condition.
+type Condition interface {
+	// GroupVersionKind identifies which watch this condition should be fed
+	// events from.
+	GroupVersionKind() schema.GroupVersionKind
+
+	// ListOptions narrows the watch (namespace, label selector, ...).
+	ListOptions() []client.ListOption
+
+	// Matches is called once per watch event for objects of
+	// GroupVersionKind; it returns true once the condition is satisfied.
+	// Implementations should be tolerant of being called with the same
+	// object more than once (e.g. after a resync).
+	Matches(event watch.Event) (bool, error)
+
+	// String describes the condition for failure messages and the
+	// recorded transition log.
+	String() string
+}
+
+// selfWatching is an optional interface a Condition can implement when it
+// can't be served off the shared per-ManagementCluster informer, e.g.
+// WorkloadNodesReady, which watches Nodes in the workload cluster rather
+// than objects in the management cluster.
+type selfWatching interface {
+	watch(ctx context.Context, mgmt ManagementCluster) (<-chan watch.Event, error)
+}
+
+// transition is one observed event relevant to a For() call, kept so a
+// timeout error can show a real timeline instead of a single "not ready"
+// message.
+type transition struct {
+	at        time.Time
+	condition string
+	summary   string
+}
+
+// For blocks until every condition is satisfied or timeout elapses,
+// multiplexing watches through the shared Informer for mgmt. On timeout the
+// returned error includes the transitions observed for any condition that
+// never matched.
+func For(ctx context.Context, mgmt ManagementCluster, timeout time.Duration, conditions ...Condition) error {
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	remaining := make(map[Condition]bool, len(conditions))
+	for _, c := range conditions {
+		remaining[c] = true
+	}
+
+	var mu sync.Mutex
+	var transitions []transition
+
+	unsubscribe := make([]func(), 0, len(conditions))
+	done := make(chan struct{})
+
+	record := func(c Condition, event watch.Event) {
+		ok, err := c.Matches(event)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			transitions = append(transitions, transition{at: now(), condition: c.String(), summary: fmt.Sprintf("error: %v", err)})
+			return
+		}
+		if !ok {
+			if remaining[c] {
+				transitions = append(transitions, transition{at: now(), condition: c.String(), summary: fmt.Sprintf("%s event, not yet satisfied", event.Type)})
+			}
+			return
+		}
+		if remaining[c] {
+			transitions = append(transitions, transition{at: now(), condition: c.String(), summary: "matched"})
+			delete(remaining, c)
+			if len(remaining) == 0 {
+				close(done)
+			}
+		}
+	}
+
+	var informer *Informer
+	for _, c := range conditions {
+		c := c
+
+		if sw, ok := c.(selfWatching); ok {
+			events, err := sw.watch(ctx, mgmt)
+			if err != nil {
+				return errors.Wrapf(err, "failed to start watch for condition %s", c)
+			}
+			watchCtx, watchCancel := context.WithCancel(ctx)
+			go func() {
+				for {
+					select {
+					case <-watchCtx.Done():
+						return
+					case event, ok := <-events:
+						if !ok {
+							return
+						}
+						record(c, event)
+					}
+				}
+			}()
+			unsubscribe = append(unsubscribe, watchCancel)
+			continue
+		}
+
+		if informer == nil {
+			var err error
+			informer, err = sharedInformer(mgmt)
+			if err != nil {
+				return err
+			}
+		}
+		sub := informer.subscribe(c.GroupVersionKind(), c.ListOptions(), func(event watch.Event) {
+			record(c, event)
+		})
+		unsubscribe = append(unsubscribe, sub)
+	}
+	defer func() {
+		for _, u := range unsubscribe {
+			u()
+		}
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		mu.Lock()
+		defer mu.Unlock()
+		return timeoutError(remaining, transitions)
+	}
+}
+
+func timeoutError(remaining map[Condition]bool, transitions []transition) error {
+	msg := "timed out waiting for conditions:\n"
+	for c := range remaining {
+		msg += fmt.Sprintf("  - %s\n", c)
+	}
+	if len(transitions) > 0 {
+		msg += "observed transitions:\n"
+		for _, t := range transitions {
+			msg += fmt.Sprintf("  [%s] %s: %s\n", t.at.Format(time.RFC3339), t.condition, t.summary)
+		}
+	}
+	return errors.New(msg)
+}
+
+// now is a var so tests can stub it; production code always uses time.Now.
+var now = time.Now
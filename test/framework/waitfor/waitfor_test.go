@@ -0,0 +1,78 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package waitfor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeManagementCluster hands out a fixed client.Client for GetClient;
+// GetWorkloadClient is unused by the conditions under test here.
+type fakeManagementCluster struct {
+	client client.Client
+}
+
+func (f *fakeManagementCluster) GetClient() (client.Client, error) {
+	return f.client, nil
+}
+
+func (f *fakeManagementCluster) GetWorkloadClient(context.Context, string, string) (client.Client, error) {
+	return nil, errors.New("fakeManagementCluster does not support GetWorkloadClient")
+}
+
+func TestForReturnsImmediatelyWhenConditionAlreadyTrue(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &clusterv1.Cluster{}
+	cluster.Name = "cluster1"
+	cluster.Status.Phase = string(clusterv1.ClusterPhaseProvisioned)
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cluster)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	fake := &fakeWatchClient{listItems: []unstructured.Unstructured{{Object: m}}}
+	mgmt := &fakeManagementCluster{client: fake}
+
+	// Reset the package-level informer registry so this test doesn't reuse
+	// an Informer left behind by another test's ManagementCluster.
+	informerRegistryMu.Lock()
+	informerRegistry = map[ManagementCluster]*Informer{}
+	informerRegistryMu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- For(context.Background(), mgmt, time.Second,
+			ClusterPhase(types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name}, clusterv1.ClusterPhaseProvisioned),
+		)
+	}()
+
+	select {
+	case err := <-done:
+		g.Expect(err).NotTo(HaveOccurred())
+	case <-time.After(time.Second):
+		t.Fatal("For() did not return for a condition that was already true before it was called")
+	}
+}